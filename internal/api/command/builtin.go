@@ -0,0 +1,181 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// GroupInviter is the subset of the group RPC client the built-in commands
+// need in order to perform membership and group info side effects.
+type GroupInviter interface {
+	InviteUserToGroup(ctx context.Context, groupID, reason string, userIDs []string) error
+	KickGroupMember(ctx context.Context, groupID, reason string, userIDs []string) error
+	SetGroupInfo(ctx context.Context, groupID string, groupName, notification, introduction string) error
+}
+
+// awayCommand posts an ephemeral confirmation; the actual "away" state is
+// stored client-side, so the server only needs to echo it back.
+type awayCommand struct{}
+
+func (awayCommand) GetTrigger() string      { return "away" }
+func (awayCommand) GetAutoComplete() string { return "/away - mark yourself as away" }
+func (awayCommand) DoCommand(_ context.Context, _ string, _ *RawMessage) (*CommandResponse, error) {
+	return &CommandResponse{SuppressOriginal: true, EphemeralReply: "You are now marked as away."}, nil
+}
+
+// meCommand rewrites "/me is happy" into a third-person action line.
+type meCommand struct{}
+
+func (meCommand) GetTrigger() string      { return "me" }
+func (meCommand) GetAutoComplete() string { return "/me <action> - describe an action" }
+func (meCommand) DoCommand(_ context.Context, args string, raw *RawMessage) (*CommandResponse, error) {
+	if args == "" {
+		return nil, errs.ErrArgs.WrapMsg("/me requires a description")
+	}
+	return &CommandResponse{RewrittenContent: fmt.Sprintf("* %s %s", raw.SendID, args)}, nil
+}
+
+// shrugCommand appends the classic shrug emoticon to the message.
+type shrugCommand struct{}
+
+func (shrugCommand) GetTrigger() string      { return "shrug" }
+func (shrugCommand) GetAutoComplete() string { return "/shrug <text> - append ¯\\_(ツ)_/¯" }
+func (shrugCommand) DoCommand(_ context.Context, args string, _ *RawMessage) (*CommandResponse, error) {
+	content := strings.TrimSpace(args + ` ¯\_(ツ)_/¯`)
+	return &CommandResponse{RewrittenContent: content}, nil
+}
+
+// inviteCommand adds one or more @mentioned users to the current group.
+type inviteCommand struct {
+	groupClient GroupInviter
+}
+
+func newInviteCommand(groupClient GroupInviter) *inviteCommand {
+	return &inviteCommand{groupClient: groupClient}
+}
+
+func (*inviteCommand) GetTrigger() string      { return "invite" }
+func (*inviteCommand) GetAutoComplete() string { return "/invite @user - add a user to this group" }
+func (c *inviteCommand) DoCommand(ctx context.Context, args string, raw *RawMessage) (*CommandResponse, error) {
+	if raw.GroupID == "" {
+		return nil, errs.ErrArgs.WrapMsg("/invite can only be used inside a group")
+	}
+	userIDs := parseMentionedUserIDs(args)
+	if len(userIDs) == 0 {
+		return nil, errs.ErrArgs.WrapMsg("/invite requires at least one @user")
+	}
+	if err := c.groupClient.InviteUserToGroup(ctx, raw.GroupID, "invited via /invite", userIDs); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{
+		SuppressOriginal:   true,
+		SystemNotification: fmt.Sprintf("%s invited %s to the group.", raw.SendID, strings.Join(userIDs, ", ")),
+	}, nil
+}
+
+// leaveCommand removes the sender from the current group.
+type leaveCommand struct {
+	groupClient GroupInviter
+}
+
+func newLeaveCommand(groupClient GroupInviter) *leaveCommand {
+	return &leaveCommand{groupClient: groupClient}
+}
+
+func (*leaveCommand) GetTrigger() string      { return "leave" }
+func (*leaveCommand) GetAutoComplete() string { return "/leave - leave this group" }
+func (c *leaveCommand) DoCommand(ctx context.Context, _ string, raw *RawMessage) (*CommandResponse, error) {
+	if raw.GroupID == "" {
+		return nil, errs.ErrArgs.WrapMsg("/leave can only be used inside a group")
+	}
+	if err := c.groupClient.KickGroupMember(ctx, raw.GroupID, "left via /leave", []string{raw.SendID}); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{
+		SuppressOriginal:   true,
+		SystemNotification: fmt.Sprintf("%s left the group.", raw.SendID),
+	}, nil
+}
+
+// headerCommand sets the group's notification/header text.
+type headerCommand struct {
+	groupClient GroupInviter
+}
+
+func newHeaderCommand(groupClient GroupInviter) *headerCommand {
+	return &headerCommand{groupClient: groupClient}
+}
+
+func (*headerCommand) GetTrigger() string      { return "header" }
+func (*headerCommand) GetAutoComplete() string { return "/header <text> - set the group header" }
+func (c *headerCommand) DoCommand(ctx context.Context, args string, raw *RawMessage) (*CommandResponse, error) {
+	if raw.GroupID == "" {
+		return nil, errs.ErrArgs.WrapMsg("/header can only be used inside a group")
+	}
+	if err := c.groupClient.SetGroupInfo(ctx, raw.GroupID, "", args, ""); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{SuppressOriginal: true, EphemeralReply: "Group header updated."}, nil
+}
+
+// purposeCommand sets the group's introduction/purpose text.
+type purposeCommand struct {
+	groupClient GroupInviter
+}
+
+func newPurposeCommand(groupClient GroupInviter) *purposeCommand {
+	return &purposeCommand{groupClient: groupClient}
+}
+
+func (*purposeCommand) GetTrigger() string      { return "purpose" }
+func (*purposeCommand) GetAutoComplete() string { return "/purpose <text> - set the group purpose" }
+func (c *purposeCommand) DoCommand(ctx context.Context, args string, raw *RawMessage) (*CommandResponse, error) {
+	if raw.GroupID == "" {
+		return nil, errs.ErrArgs.WrapMsg("/purpose can only be used inside a group")
+	}
+	if err := c.groupClient.SetGroupInfo(ctx, raw.GroupID, "", "", args); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{SuppressOriginal: true, EphemeralReply: "Group purpose updated."}, nil
+}
+
+func parseMentionedUserIDs(args string) []string {
+	fields := strings.Fields(args)
+	userIDs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if userID := strings.TrimPrefix(field, "@"); userID != field && userID != "" {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs
+}
+
+// RegisterBuiltins wires the stock command set into r, reusing the given
+// group RPC client for the commands that perform group membership and group
+// info side effects.
+func RegisterBuiltins(r *Registry, groupClient GroupInviter) {
+	r.Register(awayCommand{})
+	r.Register(meCommand{})
+	r.Register(shrugCommand{})
+	r.Register(newInviteCommand(groupClient))
+	r.Register(newLeaveCommand(groupClient))
+	r.Register(newHeaderCommand(groupClient))
+	r.Register(newPurposeCommand(groupClient))
+}
@@ -0,0 +1,135 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package command implements a pluggable slash-command framework for the
+// message sending pipeline, similar in spirit to Mattermost's slash
+// commands: a leading "/" in a text/markdown message is parsed into a
+// trigger and arguments and dispatched to a registered CommandProvider
+// instead of being relayed as plain text.
+package command
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RawMessage is the subset of the incoming message that a CommandProvider
+// needs in order to perform its side effects.
+type RawMessage struct {
+	SendID      string
+	GroupID     string
+	RecvID      string
+	SessionType int32
+	ContentType int32
+}
+
+// CommandResponse describes how a dispatched command wants to affect message
+// delivery.
+type CommandResponse struct {
+	// SuppressOriginal, when true, stops the triggering message from being
+	// forwarded to the conversation.
+	SuppressOriginal bool
+	// RewrittenContent, if non-empty, replaces the content that is
+	// forwarded instead of suppressing it entirely.
+	RewrittenContent string
+	// EphemeralReply, if non-empty, is shown only to the sender and is
+	// never persisted or delivered to other participants.
+	EphemeralReply string
+	// SystemNotification, if non-empty, is emitted into the conversation as
+	// a system/notification message visible to all participants.
+	SystemNotification string
+}
+
+// CommandProvider implements the side effects of a single slash command.
+type CommandProvider interface {
+	// GetTrigger returns the command word without the leading "/" (e.g. "away").
+	GetTrigger() string
+	// GetAutoComplete returns the hint shown to clients building an
+	// autocomplete list, e.g. "/invite @user".
+	GetAutoComplete() string
+	// DoCommand executes the command and returns how it should affect delivery.
+	DoCommand(ctx context.Context, args string, rawMsg *RawMessage) (*CommandResponse, error)
+}
+
+// Registry holds the set of commands that SendMessage / SendSimpleMessage
+// dispatch into.
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]CommandProvider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]CommandProvider)}
+}
+
+// Register adds a provider, overwriting any existing provider for the same trigger.
+func (r *Registry) Register(provider CommandProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[provider.GetTrigger()] = provider
+}
+
+// List returns the autocomplete hints for every registered command, sorted
+// by trigger for a stable client-side listing.
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	triggers := make([]string, 0, len(r.commands))
+	for trigger := range r.commands {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+	hints := make([]string, 0, len(triggers))
+	for _, trigger := range triggers {
+		hints = append(hints, r.commands[trigger].GetAutoComplete())
+	}
+	return hints
+}
+
+// ParseTrigger splits a leading "/trigger args..." message body into its
+// trigger and remaining args. ok is false when content does not start with "/".
+func ParseTrigger(content string) (trigger string, args string, ok bool) {
+	if !strings.HasPrefix(content, "/") {
+		return "", "", false
+	}
+	trimmed := strings.TrimPrefix(content, "/")
+	trigger, args, _ = strings.Cut(trimmed, " ")
+	return trigger, strings.TrimSpace(args), true
+}
+
+// Dispatch parses content for a leading slash command and, if one matches a
+// registered provider, runs it. ok is false when content was not a command,
+// or started with "/" but didn't match any registered trigger (e.g. a
+// message that just happens to start with "/", or a client-only command no
+// provider handles) — in either case the caller should forward the original
+// message unchanged rather than fail the send.
+func (r *Registry) Dispatch(ctx context.Context, content string, rawMsg *RawMessage) (resp *CommandResponse, ok bool, err error) {
+	trigger, args, isCommand := ParseTrigger(content)
+	if !isCommand {
+		return nil, false, nil
+	}
+	r.mu.RLock()
+	provider, found := r.commands[trigger]
+	r.mu.RUnlock()
+	if !found {
+		return nil, false, nil
+	}
+	resp, err = provider.DoCommand(ctx, args, rawMsg)
+	if err != nil {
+		return nil, true, err
+	}
+	return resp, true, nil
+}
@@ -0,0 +1,111 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/openimsdk/tools/errs"
+)
+
+// WebhookCommandConfig is one admin-registered external command handler, as
+// loaded from config (see config.AfterConfig-style webhook entries elsewhere
+// in this service).
+type WebhookCommandConfig struct {
+	Trigger      string `mapstructure:"trigger"`
+	AutoComplete string `mapstructure:"autoComplete"`
+	URL          string `mapstructure:"url"`
+	TimeoutMS    int    `mapstructure:"timeoutMS"`
+}
+
+type webhookRequestBody struct {
+	Trigger string `json:"trigger"`
+	Args    string `json:"args"`
+	SendID  string `json:"sendID"`
+	GroupID string `json:"groupID"`
+	RecvID  string `json:"recvID"`
+}
+
+type webhookResponseBody struct {
+	SuppressOriginal   bool   `json:"suppressOriginal"`
+	RewrittenContent   string `json:"rewrittenContent"`
+	EphemeralReply     string `json:"ephemeralReply"`
+	SystemNotification string `json:"systemNotification"`
+}
+
+// webhookCommand forwards the command invocation to an admin-registered
+// external HTTP handler and maps its JSON response back into a CommandResponse.
+type webhookCommand struct {
+	cfg    WebhookCommandConfig
+	client *http.Client
+}
+
+func newWebhookCommand(cfg WebhookCommandConfig) *webhookCommand {
+	timeout := time.Duration(cfg.TimeoutMS) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &webhookCommand{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *webhookCommand) GetTrigger() string      { return c.cfg.Trigger }
+func (c *webhookCommand) GetAutoComplete() string { return c.cfg.AutoComplete }
+
+func (c *webhookCommand) DoCommand(ctx context.Context, args string, raw *RawMessage) (*CommandResponse, error) {
+	body, err := json.Marshal(webhookRequestBody{
+		Trigger: c.cfg.Trigger,
+		Args:    args,
+		SendID:  raw.SendID,
+		GroupID: raw.GroupID,
+		RecvID:  raw.RecvID,
+	})
+	if err != nil {
+		return nil, errs.WrapMsg(err, "failed to encode webhook command request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errs.WrapMsg(err, "failed to build webhook command request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errs.WrapMsg(err, "webhook command request failed", "trigger", c.cfg.Trigger)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.ErrInternalServer.WrapMsg("webhook command returned non-200 status", "trigger", c.cfg.Trigger)
+	}
+	var out webhookResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, errs.WrapMsg(err, "failed to decode webhook command response")
+	}
+	return &CommandResponse{
+		SuppressOriginal:   out.SuppressOriginal,
+		RewrittenContent:   out.RewrittenContent,
+		EphemeralReply:     out.EphemeralReply,
+		SystemNotification: out.SystemNotification,
+	}, nil
+}
+
+// RegisterWebhooks wires the admin-configured webhook commands into r.
+func RegisterWebhooks(r *Registry, configs []WebhookCommandConfig) {
+	for _, cfg := range configs {
+		r.Register(newWebhookCommand(cfg))
+	}
+}
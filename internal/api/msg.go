@@ -15,6 +15,7 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"sync"
@@ -24,8 +25,8 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"google.golang.org/protobuf/reflect/protoreflect"
 
+	"github.com/openimsdk/open-im-server/v3/internal/api/command"
 	"github.com/openimsdk/open-im-server/v3/pkg/apistruct"
-	"github.com/openimsdk/open-im-server/v3/pkg/authverify"
 	"github.com/openimsdk/open-im-server/v3/pkg/common/config"
 	"github.com/openimsdk/open-im-server/v3/pkg/common/webhook"
 	"github.com/openimsdk/open-im-server/v3/pkg/rpcli"
@@ -81,10 +82,46 @@ type MessageApi struct {
 	userClient    *rpcli.UserClient
 	imAdminUserID []string
 	validate      *validator.Validate
+	inputStatus   *inputStatusThrottle
+	commands      *command.Registry
+	permissions   *MessagePermissionChecker
+	batchJobs     BatchJobStore
+	scheduledMsgs ScheduledMessageStore
 }
 
-func NewMessageApi(client msg.MsgClient, userClient *rpcli.UserClient, imAdminUserID []string) MessageApi {
-	return MessageApi{Client: client, userClient: userClient, imAdminUserID: imAdminUserID, validate: validator.New()}
+// NewMessageApi wires up the message API handlers. groupClient is reused by
+// the built-in slash commands (/invite, /leave, /header, /purpose) to carry
+// out their group membership and group info side effects, webhookCommands registers any
+// admin-configured external command handlers, capabilityResolver resolves
+// the per-user/per-group capabilities consulted by MessagePermissionChecker
+// in place of a blanket admin gate, and batchJobs/scheduledMsgs are the
+// durable (Redis/Mongo-backed) stores a production deployment must supply;
+// pass nil for either to fall back to the single-process,
+// restart-loses-everything in-memory implementation, which logs a warning
+// at startup so that fallback is never silent.
+func NewMessageApi(client msg.MsgClient, userClient *rpcli.UserClient, groupClient command.GroupInviter, imAdminUserID []string, webhookCommands []command.WebhookCommandConfig, capabilityResolver CapabilityResolver, batchJobs BatchJobStore, scheduledMsgs ScheduledMessageStore) MessageApi {
+	commands := command.NewRegistry()
+	command.RegisterBuiltins(commands, groupClient)
+	command.RegisterWebhooks(commands, webhookCommands)
+	if batchJobs == nil {
+		log.ZWarn(context.Background(), "no durable BatchJobStore configured, falling back to in-memory", nil)
+		batchJobs = newInMemoryBatchJobStore()
+	}
+	if scheduledMsgs == nil {
+		log.ZWarn(context.Background(), "no durable ScheduledMessageStore configured, falling back to in-memory", nil)
+		scheduledMsgs = newInMemoryScheduledMessageStore()
+	}
+	return MessageApi{
+		Client:        client,
+		userClient:    userClient,
+		imAdminUserID: imAdminUserID,
+		validate:      validator.New(),
+		inputStatus:   newInputStatusThrottle(),
+		commands:      commands,
+		permissions:   NewMessagePermissionChecker(imAdminUserID, capabilityResolver),
+		batchJobs:     batchJobs,
+		scheduledMsgs: scheduledMsgs,
+	}
 }
 
 func (*MessageApi) SetOptions(options map[string]bool, value bool) {
@@ -94,7 +131,7 @@ func (*MessageApi) SetOptions(options map[string]bool, value bool) {
 	datautil.SetSwitchFromOptions(options, constant.IsConversationUpdate, value)
 }
 
-func (m *MessageApi) newUserSendMsgReq(_ *gin.Context, params *apistruct.SendMsg, data any) *msg.SendMsgReq {
+func (m *MessageApi) newUserSendMsgReq(_ context.Context, params *apistruct.SendMsg, data any) *msg.SendMsgReq {
 	msgData := &sdkws.MsgData{
 		SendID:           params.SendID,
 		GroupID:          params.GroupID,
@@ -199,9 +236,9 @@ func (m *MessageApi) DeleteMsgPhysical(c *gin.Context) {
 	a2r.Call(c, msg.MsgClient.DeleteMsgPhysical, m.Client)
 }
 
-func (m *MessageApi) getSendMsgReq(c *gin.Context, req apistruct.SendMsg) (sendMsgReq *msg.SendMsgReq, err error) {
+func (m *MessageApi) getSendMsgReq(ctx context.Context, req apistruct.SendMsg) (sendMsgReq *msg.SendMsgReq, err error) {
 	var data any
-	log.ZDebug(c, "getSendMsgReq", "req", req.Content)
+	log.ZDebug(ctx, "getSendMsgReq", "req", req.Content)
 	switch req.ContentType {
 	case constant.Text:
 		data = &apistruct.TextElem{}
@@ -222,7 +259,7 @@ func (m *MessageApi) getSendMsgReq(c *gin.Context, req apistruct.SendMsg) (sendM
 	case constant.OANotification:
 		data = &apistruct.OANotificationElem{}
 		req.SessionType = constant.NotificationChatType
-		if err = m.userClient.GetNotificationByID(c, req.SendID); err != nil {
+		if err = m.userClient.GetNotificationByID(ctx, req.SendID); err != nil {
 			return nil, err
 		}
 	default:
@@ -231,11 +268,15 @@ func (m *MessageApi) getSendMsgReq(c *gin.Context, req apistruct.SendMsg) (sendM
 	if err := mapstructure.WeakDecode(req.Content, data); err != nil {
 		return nil, errs.WrapMsg(err, "failed to decode message content")
 	}
-	log.ZDebug(c, "getSendMsgReq", "decodedContent", data)
+	log.ZDebug(ctx, "getSendMsgReq", "decodedContent", data)
 	if err := m.validate.Struct(data); err != nil {
 		return nil, errs.WrapMsg(err, "validation error")
 	}
-	return m.newUserSendMsgReq(c, &req, data), nil
+	// "@all" and impersonation are authorized by the caller against the
+	// resulting MsgData (GroupID/SendID/AtUserIDList), not here: see
+	// checkSendMessagePermission for SendMessage, and the equivalent
+	// pre-send checks in BatchSendMsg/BatchSendMsgStream/BatchSendMsgResume.
+	return m.newUserSendMsgReq(ctx, &req, data), nil
 }
 
 func (m *MessageApi) getModifyFields(req, respModify *sdkws.MsgData) map[string]any {
@@ -286,13 +327,6 @@ func (m *MessageApi) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Check if the user has the app manager role.
-	if !authverify.IsAdmin(c) {
-		// Respond with a permission error if the user is not an app manager.
-		apiresp.GinError(c, errs.ErrNoPermission.WrapMsg("only app manager can send message"))
-		return
-	}
-
 	// Prepare the message request with additional required data.
 	sendMsgReq, err := m.getSendMsgReq(c, req.SendMsg)
 	if err != nil {
@@ -304,6 +338,29 @@ func (m *MessageApi) SendMessage(c *gin.Context) {
 	// Set the receiver ID in the message data.
 	sendMsgReq.MsgData.RecvID = req.RecvID
 
+	// Evaluate the sender's capabilities for this specific send, re-resolved
+	// on every request so a capability revoked mid-session takes effect
+	// immediately instead of requiring the user to be globally kicked.
+	if err := m.checkSendMessagePermission(c, sendMsgReq.MsgData); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+
+	// Give slash commands a chance to intercept the message before it is forwarded.
+	cmdResp, suppressed, err := m.dispatchCommand(c, sendMsgReq.MsgData)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if cmdResp != nil && cmdResp.EphemeralReply != "" {
+		apiresp.GinSuccess(c, &apistruct.SendMsgResp{Modify: map[string]any{"ephemeralReply": cmdResp.EphemeralReply}})
+		return
+	}
+	if suppressed {
+		apiresp.GinSuccess(c, nil)
+		return
+	}
+
 	// Attempt to send the message using the client.
 	respPb, err := m.Client.SendMsg(c, sendMsgReq)
 	if err != nil {
@@ -361,8 +418,17 @@ func (m *MessageApi) SendBusinessNotification(c *gin.Context) {
 	if req.ReliabilityLevel == nil {
 		req.ReliabilityLevel = datautil.ToPtr(1)
 	}
-	if !authverify.IsAdmin(c) {
-		apiresp.GinError(c, errs.ErrNoPermission.WrapMsg("only app manager can send message"))
+	caps, err := m.permissions.Resolve(c, req.SendUserID, req.RecvGroupID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := Require(caps, CapSendOANotification); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := m.requireImpersonation(c, req.SendUserID, req.RecvGroupID); err != nil {
+		apiresp.GinError(c, err)
 		return
 	}
 	sendMsgReq := msg.SendMsgReq{
@@ -396,63 +462,6 @@ func (m *MessageApi) SendBusinessNotification(c *gin.Context) {
 	m.ginRespSendMsg(c, &sendMsgReq, respPb)
 }
 
-func (m *MessageApi) BatchSendMsg(c *gin.Context) {
-	var (
-		req  apistruct.BatchSendMsgReq
-		resp apistruct.BatchSendMsgResp
-	)
-	if err := c.BindJSON(&req); err != nil {
-		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
-		return
-	}
-	if err := authverify.CheckAdmin(c); err != nil {
-		apiresp.GinError(c, errs.ErrNoPermission.WrapMsg("only app manager can send message"))
-		return
-	}
-
-	var recvIDs []string
-	if req.IsSendAll {
-		var pageNumber int32 = 1
-		const showNumber = 500
-		for {
-			recvIDsPart, err := m.userClient.GetAllUserIDs(c, pageNumber, showNumber)
-			if err != nil {
-				apiresp.GinError(c, err)
-				return
-			}
-			recvIDs = append(recvIDs, recvIDsPart...)
-			if len(recvIDsPart) < showNumber {
-				break
-			}
-			pageNumber++
-		}
-	} else {
-		recvIDs = req.RecvIDs
-	}
-	log.ZDebug(c, "BatchSendMsg nums", "nums ", len(recvIDs))
-	sendMsgReq, err := m.getSendMsgReq(c, req.SendMsg)
-	if err != nil {
-		apiresp.GinError(c, err)
-		return
-	}
-	for _, recvID := range recvIDs {
-		sendMsgReq.MsgData.RecvID = recvID
-		rpcResp, err := m.Client.SendMsg(c, sendMsgReq)
-		if err != nil {
-			resp.FailedIDs = append(resp.FailedIDs, recvID)
-			continue
-		}
-		resp.Results = append(resp.Results, &apistruct.SingleReturnResult{
-			ServerMsgID: rpcResp.ServerMsgID,
-			ClientMsgID: rpcResp.ClientMsgID,
-			SendTime:    rpcResp.SendTime,
-			RecvID:      recvID,
-			Modify:      m.getModifyFields(sendMsgReq.MsgData, rpcResp.Modify),
-		})
-	}
-	apiresp.GinSuccess(c, resp)
-}
-
 func (m *MessageApi) SendSimpleMessage(c *gin.Context) {
 	encodedKey, ok := c.GetQuery(webhook.Key)
 	if !ok {
@@ -519,6 +528,20 @@ func (m *MessageApi) SendSimpleMessage(c *gin.Context) {
 		Ex:               req.Ex,
 	}
 
+	cmdResp, suppressed, err := m.dispatchCommand(c, msgData)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if cmdResp != nil && cmdResp.EphemeralReply != "" {
+		apiresp.GinSuccess(c, &apistruct.SendMsgResp{Modify: map[string]any{"ephemeralReply": cmdResp.EphemeralReply}})
+		return
+	}
+	if suppressed {
+		apiresp.GinSuccess(c, nil)
+		return
+	}
+
 	sendReq := &msg.SendSimpleMsgReq{
 		MsgData: msgData,
 	}
@@ -0,0 +1,478 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/openimsdk/open-im-server/v3/pkg/apistruct"
+	"github.com/openimsdk/protocol/msg"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/datautil"
+	"github.com/openimsdk/tools/utils/idutil"
+	"github.com/openimsdk/tools/utils/timeutil"
+)
+
+const (
+	defaultBatchConcurrency = 16
+	batchSendMaxRetries     = 2
+	batchSendRetryBaseDelay = 100 * time.Millisecond
+)
+
+// BatchRecipientResult is the per-recipient outcome of a batch send,
+// persisted so GET /msg/batch_send_status/:jobID can report progress and
+// POST /msg/batch_send_resume/:jobID can retry only the recipients that failed.
+type BatchRecipientResult struct {
+	RecvID      string `json:"recvID"`
+	ServerMsgID string `json:"serverMsgID,omitempty"`
+	ClientMsgID string `json:"clientMsgID,omitempty"`
+	SendTime    int64  `json:"sendTime,omitempty"`
+	Err         string `json:"err,omitempty"`
+}
+
+// BatchJob tracks the progress of one batch_send_stream (BatchSendMsgStream)
+// run; plain BatchSendMsg does not record a job.
+// OwnerUserID is the caller that started the job (not necessarily the
+// message's SendID, which may be impersonated by an admin); BatchSendMsgResume
+// scopes a resume to this field so a guessed/leaked jobID cannot be used to
+// drive sends on someone else's behalf.
+type BatchJob struct {
+	JobID       string                           `json:"jobID"`
+	OwnerUserID string                           `json:"ownerUserID"`
+	Total       int                              `json:"total"`
+	Done        int                              `json:"done"`
+	Failed      int                              `json:"failed"`
+	Finished    bool                             `json:"finished"`
+	Recipients  map[string]*BatchRecipientResult `json:"recipients"`
+}
+
+// BatchJobStore persists batch job progress so it survives across requests
+// (and, in a multi-replica deployment, across API pods). A production
+// deployment must pass NewMessageApi a Redis-backed implementation, keyed by
+// batchJobID; no such implementation ships in this package.
+// newInMemoryBatchJobStore is only the single-process fallback NewMessageApi
+// uses when none is supplied, and it loses all job state on restart — resume
+// after a crash and status/resume across replicas do not work against it.
+type BatchJobStore interface {
+	CreateJob(ctx context.Context, jobID, ownerUserID string, recvIDs []string) error
+	UpdateRecipient(ctx context.Context, jobID string, result *BatchRecipientResult) error
+	FinishJob(ctx context.Context, jobID string) error
+	GetJob(ctx context.Context, jobID string) (*BatchJob, error)
+	FailedRecipients(ctx context.Context, jobID string) ([]string, error)
+}
+
+type inMemoryBatchJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*BatchJob
+}
+
+func newInMemoryBatchJobStore() *inMemoryBatchJobStore {
+	return &inMemoryBatchJobStore{jobs: make(map[string]*BatchJob)}
+}
+
+func (s *inMemoryBatchJobStore) CreateJob(_ context.Context, jobID, ownerUserID string, recvIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID] = &BatchJob{
+		JobID:       jobID,
+		OwnerUserID: ownerUserID,
+		Total:       len(recvIDs),
+		Recipients:  make(map[string]*BatchRecipientResult, len(recvIDs)),
+	}
+	return nil
+}
+
+func (s *inMemoryBatchJobStore) UpdateRecipient(_ context.Context, jobID string, result *BatchRecipientResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return errs.ErrArgs.WrapMsg("unknown batch job", "jobID", jobID)
+	}
+	job.Recipients[result.RecvID] = result
+	job.Done++
+	if result.Err != "" {
+		job.Failed++
+	}
+	return nil
+}
+
+func (s *inMemoryBatchJobStore) FinishJob(_ context.Context, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[jobID]; ok {
+		job.Finished = true
+	}
+	return nil
+}
+
+func (s *inMemoryBatchJobStore) GetJob(_ context.Context, jobID string) (*BatchJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, errs.ErrArgs.WrapMsg("unknown batch job", "jobID", jobID)
+	}
+	return job, nil
+}
+
+func (s *inMemoryBatchJobStore) FailedRecipients(_ context.Context, jobID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, errs.ErrArgs.WrapMsg("unknown batch job", "jobID", jobID)
+	}
+	var failed []string
+	for recvID, result := range job.Recipients {
+		if result.Err != "" {
+			failed = append(failed, recvID)
+		}
+	}
+	return failed, nil
+}
+
+// batchConcurrencyAndRate reads the optional "concurrency" and "ratePerSec"
+// query parameters shared by the batch send endpoints.
+func batchConcurrencyAndRate(c *gin.Context) (concurrency, ratePerSec int) {
+	concurrency = defaultBatchConcurrency
+	if v, err := strconv.Atoi(c.Query("concurrency")); err == nil && v > 0 {
+		concurrency = v
+	}
+	if v, err := strconv.Atoi(c.Query("ratePerSec")); err == nil && v > 0 {
+		ratePerSec = v
+	}
+	return concurrency, ratePerSec
+}
+
+// batchSendOne sends a copy of template to a single recvID, retrying a failed
+// RPC a bounded number of times with a short exponential backoff. Errors are
+// not classified: a permanent error is retried just like a transient one.
+func (m *MessageApi) batchSendOne(ctx context.Context, template *msg.SendMsgReq, recvID string) *BatchRecipientResult {
+	msgData, _ := proto.Clone(template.MsgData).(*sdkws.MsgData)
+	msgData.RecvID = recvID
+	// Mix in the send time so two distinct broadcasts from the same sender to
+	// the same recvID don't land on the same ClientMsgID and have the second
+	// one silently dropped by server-side dedup.
+	msgData.ClientMsgID = idutil.GetMsgIDByMD5(msgData.SendID + recvID + strconv.FormatInt(timeutil.GetCurrentTimestampByMill(), 10))
+	req := &msg.SendMsgReq{MsgData: msgData}
+
+	var lastErr error
+	for attempt := 0; attempt <= batchSendMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(batchSendRetryBaseDelay * time.Duration(uint(1)<<uint(attempt-1)))
+		}
+		resp, err := m.Client.SendMsg(ctx, req)
+		if err == nil {
+			return &BatchRecipientResult{RecvID: recvID, ServerMsgID: resp.ServerMsgID, ClientMsgID: resp.ClientMsgID, SendTime: resp.SendTime}
+		}
+		lastErr = err
+	}
+	return &BatchRecipientResult{RecvID: recvID, Err: lastErr.Error()}
+}
+
+// runBatchSend fans work out over a bounded worker pool, optionally
+// throttled to ratePerSec messages/second so a broadcast to a large tenant
+// cannot saturate the msg RPC. onResult is invoked concurrently from the
+// worker goroutines, so any locking it needs is the caller's responsibility.
+func runBatchSend(recvIDs []string, concurrency, ratePerSec int, send func(recvID string) *BatchRecipientResult, onResult func(*BatchRecipientResult)) {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	var throttle <-chan time.Time
+	if ratePerSec > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(ratePerSec))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, recvID := range recvIDs {
+		if throttle != nil {
+			<-throttle
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(recvID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			onResult(send(recvID))
+		}(recvID)
+	}
+	wg.Wait()
+}
+
+// BatchSendMsg fans SendMsg calls out over a bounded worker pool instead of
+// looping serially, so a large IsSendAll broadcast no longer hits a single
+// HTTP timeout wall.
+func (m *MessageApi) BatchSendMsg(c *gin.Context) {
+	var (
+		req  apistruct.BatchSendMsgReq
+		resp apistruct.BatchSendMsgResp
+	)
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	caps, err := m.permissions.Resolve(c, req.SendMsg.SendID, req.SendMsg.GroupID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	required := []Capability{CapBatchSend, CapabilityForContentType(req.SendMsg.ContentType)}
+	if req.IsSendAll {
+		required = append(required, CapSendToAll)
+	}
+	if err := Require(caps, required...); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := m.requireImpersonation(c, req.SendMsg.SendID, req.SendMsg.GroupID); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+
+	recvIDs, err := m.resolveBatchRecipients(c, &req)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	log.ZDebug(c, "BatchSendMsg nums", "nums ", len(recvIDs))
+	sendMsgReq, err := m.getSendMsgReq(c, req.SendMsg)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if datautil.Contain("all", sendMsgReq.MsgData.AtUserIDList...) {
+		if err := Require(caps, CapSendAtAll); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+
+	concurrency, ratePerSec := batchConcurrencyAndRate(c)
+	var mu sync.Mutex
+	runBatchSend(recvIDs, concurrency, ratePerSec, func(recvID string) *BatchRecipientResult {
+		return m.batchSendOne(c, sendMsgReq, recvID)
+	}, func(result *BatchRecipientResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if result.Err != "" {
+			resp.FailedIDs = append(resp.FailedIDs, result.RecvID)
+			return
+		}
+		resp.Results = append(resp.Results, &apistruct.SingleReturnResult{
+			ServerMsgID: result.ServerMsgID,
+			ClientMsgID: result.ClientMsgID,
+			SendTime:    result.SendTime,
+			RecvID:      result.RecvID,
+		})
+	})
+	apiresp.GinSuccess(c, resp)
+}
+
+// resolveBatchRecipients expands IsSendAll into the full recipient list,
+// walking every user via paged GetAllUserIDs calls, or returns req.RecvIDs otherwise.
+func (m *MessageApi) resolveBatchRecipients(ctx context.Context, req *apistruct.BatchSendMsgReq) ([]string, error) {
+	if !req.IsSendAll {
+		return req.RecvIDs, nil
+	}
+	var recvIDs []string
+	var pageNumber int32 = 1
+	const showNumber = 500
+	for {
+		recvIDsPart, err := m.userClient.GetAllUserIDs(ctx, pageNumber, showNumber)
+		if err != nil {
+			return nil, err
+		}
+		recvIDs = append(recvIDs, recvIDsPart...)
+		if len(recvIDsPart) < showNumber {
+			break
+		}
+		pageNumber++
+	}
+	return recvIDs, nil
+}
+
+// BatchSendMsgStream is the streaming counterpart of BatchSendMsg: it
+// flushes one JSON line per recipient as soon as that send completes, so
+// clients can show live progress and abort instead of waiting for the whole batch.
+func (m *MessageApi) BatchSendMsgStream(c *gin.Context) {
+	var req apistruct.BatchSendMsgReq
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	caps, err := m.permissions.Resolve(c, req.SendMsg.SendID, req.SendMsg.GroupID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	required := []Capability{CapBatchSend, CapabilityForContentType(req.SendMsg.ContentType)}
+	if req.IsSendAll {
+		required = append(required, CapSendToAll)
+	}
+	if err := Require(caps, required...); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := m.requireImpersonation(c, req.SendMsg.SendID, req.SendMsg.GroupID); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+
+	recvIDs, err := m.resolveBatchRecipients(c, &req)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	sendMsgReq, err := m.getSendMsgReq(c, req.SendMsg)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if datautil.Contain("all", sendMsgReq.MsgData.AtUserIDList...) {
+		if err := Require(caps, CapSendAtAll); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+
+	jobID := idutil.GetMsgIDByMD5(req.SendMsg.SendID + strconv.FormatInt(timeutil.GetCurrentTimestampByMill(), 10))
+	if err := m.batchJobs.CreateJob(c, jobID, mcontext.GetOpUserID(c), recvIDs); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("X-Batch-Job-ID", jobID)
+	c.Status(200)
+
+	concurrency, ratePerSec := batchConcurrencyAndRate(c)
+	var mu sync.Mutex
+	runBatchSend(recvIDs, concurrency, ratePerSec, func(recvID string) *BatchRecipientResult {
+		return m.batchSendOne(c, sendMsgReq, recvID)
+	}, func(result *BatchRecipientResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = m.batchJobs.UpdateRecipient(c, jobID, result)
+		line, _ := json.Marshal(result)
+		c.Writer.Write(append(line, '\n'))
+		c.Writer.Flush()
+	})
+	_ = m.batchJobs.FinishJob(c, jobID)
+}
+
+// BatchSendMsgStatus reports the progress of a batch job started via
+// BatchSendMsgStream.
+func (m *MessageApi) BatchSendMsgStatus(c *gin.Context) {
+	job, err := m.batchJobs.GetJob(c, c.Param("jobID"))
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, job)
+}
+
+// BatchSendMsgResume retries only the recipients that failed in a previous
+// BatchSendMsgStream run, reusing the same jobID so status keeps accumulating.
+// It is gated by the same capability checks as BatchSendMsg and scoped to the
+// job's owner, so a guessed/leaked jobID cannot be used to drive sends past
+// the capability gate on someone else's behalf.
+func (m *MessageApi) BatchSendMsgResume(c *gin.Context) {
+	jobID := c.Param("jobID")
+	job, err := m.batchJobs.GetJob(c, jobID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if job.OwnerUserID != mcontext.GetOpUserID(c) {
+		apiresp.GinError(c, errs.ErrArgs.WrapMsg("unknown batch job", "jobID", jobID))
+		return
+	}
+	failed, err := m.batchJobs.FailedRecipients(c, jobID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	var req apistruct.BatchSendMsgReq
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	caps, err := m.permissions.Resolve(c, req.SendMsg.SendID, req.SendMsg.GroupID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	required := []Capability{CapBatchSend, CapabilityForContentType(req.SendMsg.ContentType)}
+	if req.IsSendAll {
+		required = append(required, CapSendToAll)
+	}
+	if err := Require(caps, required...); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := m.requireImpersonation(c, req.SendMsg.SendID, req.SendMsg.GroupID); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+
+	sendMsgReq, err := m.getSendMsgReq(c, req.SendMsg)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if datautil.Contain("all", sendMsgReq.MsgData.AtUserIDList...) {
+		if err := Require(caps, CapSendAtAll); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+
+	concurrency, ratePerSec := batchConcurrencyAndRate(c)
+	var mu sync.Mutex
+	var resp apistruct.BatchSendMsgResp
+	runBatchSend(failed, concurrency, ratePerSec, func(recvID string) *BatchRecipientResult {
+		return m.batchSendOne(c, sendMsgReq, recvID)
+	}, func(result *BatchRecipientResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = m.batchJobs.UpdateRecipient(c, jobID, result)
+		if result.Err != "" {
+			resp.FailedIDs = append(resp.FailedIDs, result.RecvID)
+			return
+		}
+		resp.Results = append(resp.Results, &apistruct.SingleReturnResult{
+			ServerMsgID: result.ServerMsgID,
+			ClientMsgID: result.ClientMsgID,
+			SendTime:    result.SendTime,
+			RecvID:      result.RecvID,
+		})
+	})
+	apiresp.GinSuccess(c, resp)
+}
@@ -0,0 +1,98 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/openimsdk/open-im-server/v3/internal/api/command"
+	"github.com/openimsdk/open-im-server/v3/pkg/apistruct"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/protocol/msg"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/utils/idutil"
+	"github.com/openimsdk/tools/utils/jsonutil"
+	"github.com/openimsdk/tools/utils/timeutil"
+)
+
+// dispatchCommand parses md.Content for a leading slash command and, if one
+// is registered, runs it. When the message was not a command, resp is nil
+// and the caller should forward md unchanged. Otherwise the caller should
+// honour resp.EphemeralReply / suppressed before sending md.
+func (m *MessageApi) dispatchCommand(c *gin.Context, md *sdkws.MsgData) (resp *command.CommandResponse, suppressed bool, err error) {
+	if md.ContentType != constant.Text && md.ContentType != constant.MarkdownText {
+		return nil, false, nil
+	}
+	var elem struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(md.Content, &elem); err != nil {
+		// Not a recognizable text payload; let it through unchanged.
+		return nil, false, nil
+	}
+	rawMsg := &command.RawMessage{
+		SendID:      md.SendID,
+		GroupID:     md.GroupID,
+		RecvID:      md.RecvID,
+		SessionType: md.SessionType,
+		ContentType: md.ContentType,
+	}
+	resp, ok, err := m.commands.Dispatch(c, elem.Content, rawMsg)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	if resp.RewrittenContent != "" {
+		md.Content = []byte(jsonutil.StructToJsonString(&apistruct.MarkdownTextElem{Content: resp.RewrittenContent}))
+	}
+	if resp.SystemNotification != "" {
+		m.sendSystemNotification(c, md, resp.SystemNotification)
+	}
+	return resp, resp.SuppressOriginal, nil
+}
+
+// sendSystemNotification emits a system message into md's conversation. It
+// backs commands such as /invite and /leave announcing their side effects,
+// as well as other server-driven notices (e.g. a scheduled send that failed
+// permanently) that should show up as a regular message rather than an API error.
+func (m *MessageApi) sendSystemNotification(ctx context.Context, md *sdkws.MsgData, text string) {
+	notifyData := &sdkws.MsgData{
+		SendID:      md.SendID,
+		RecvID:      md.RecvID,
+		GroupID:     md.GroupID,
+		SessionType: md.SessionType,
+		MsgFrom:     constant.SysMsgType,
+		ContentType: constant.Text,
+		ClientMsgID: idutil.GetMsgIDByMD5(md.SendID),
+		CreateTime:  timeutil.GetCurrentTimestampByMill(),
+		Content:     []byte(jsonutil.StructToJsonString(&apistruct.TextElem{Content: text})),
+	}
+	if _, err := m.Client.SendMsg(ctx, &msg.SendMsgReq{MsgData: notifyData}); err != nil {
+		log.ZError(ctx, "failed to send system notification", err)
+	}
+}
+
+// ListCommands exposes the registered slash commands so clients can build an
+// autocomplete list.
+func (m *MessageApi) ListCommands(c *gin.Context) {
+	apiresp.GinSuccess(c, m.commands.List())
+}
@@ -0,0 +1,162 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/openimsdk/open-im-server/v3/pkg/apistruct"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/protocol/msg"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/utils/datautil"
+	"github.com/openimsdk/tools/utils/idutil"
+	"github.com/openimsdk/tools/utils/jsonutil"
+	"github.com/openimsdk/tools/utils/timeutil"
+)
+
+// inputStatusThrottleWindow is the minimum gap between two input status
+// updates the server will forward for the same sender+conversation pair.
+const inputStatusThrottleWindow = 500 * time.Millisecond
+
+// inputStatusThrottleSweepEvery bounds how often allow() sweeps expired
+// entries out of last, so the map does not grow without bound as new
+// sender+conversation pairs show up over the life of the process.
+const inputStatusThrottleSweepEvery = 1000
+
+// inputStatusThrottle drops duplicate typing-style updates published by the
+// same sender into the same conversation within inputStatusThrottleWindow.
+type inputStatusThrottle struct {
+	mu    sync.Mutex
+	last  map[string]time.Time
+	calls int
+}
+
+func newInputStatusThrottle() *inputStatusThrottle {
+	return &inputStatusThrottle{last: make(map[string]time.Time)}
+}
+
+func (t *inputStatusThrottle) allow(key string) bool {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	if t.calls >= inputStatusThrottleSweepEvery {
+		t.calls = 0
+		for k, last := range t.last {
+			if now.Sub(last) >= inputStatusThrottleWindow {
+				delete(t.last, k)
+			}
+		}
+	}
+	if last, ok := t.last[key]; ok && now.Sub(last) < inputStatusThrottleWindow {
+		return false
+	}
+	t.last[key] = now
+	return true
+}
+
+// SendConversationInputStatus broadcasts an ephemeral "typing / recording
+// voice / picking image / custom-XYZ" indicator into a conversation. The
+// indicator is online-only: it is never persisted, never bumps unread
+// counts, and is not delivered via offline push.
+func (m *MessageApi) SendConversationInputStatus(c *gin.Context) {
+	var req apistruct.ConversationInputStatusReq
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	if !m.inputStatus.allow(req.SendID + ":" + req.FocusConversationID) {
+		apiresp.GinSuccess(c, nil)
+		return
+	}
+	sendMsgReq, err := m.newInputStatusSendMsgReq(&req)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if _, err := m.Client.SendMsg(c, sendMsgReq); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, nil)
+}
+
+// CancelConversationInputStatus explicitly clears an indicator published by
+// SendConversationInputStatus, for clients that stop typing before ExpireMs
+// elapses.
+func (m *MessageApi) CancelConversationInputStatus(c *gin.Context) {
+	var req apistruct.CancelConversationInputStatusReq
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	sendMsgReq, err := m.newInputStatusSendMsgReq(&apistruct.ConversationInputStatusReq{
+		SendID:              req.SendID,
+		FocusConversationID: req.FocusConversationID,
+		GroupID:             req.GroupID,
+		PlatformID:          req.PlatformID,
+		Status:              "",
+		ExpireMs:            0,
+	})
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if _, err := m.Client.SendMsg(c, sendMsgReq); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, nil)
+}
+
+func (m *MessageApi) newInputStatusSendMsgReq(req *apistruct.ConversationInputStatusReq) (*msg.SendMsgReq, error) {
+	content := jsonutil.StructToJsonString(req)
+	var (
+		sessionType int32
+		recvID      string
+		groupID     string
+	)
+	if req.GroupID != "" {
+		sessionType = constant.ReadGroupChatType
+		groupID = req.GroupID
+	} else {
+		sessionType = constant.SingleChatType
+		recvID = req.FocusConversationID
+	}
+	msgData := &sdkws.MsgData{
+		SendID:           req.SendID,
+		RecvID:           recvID,
+		GroupID:          groupID,
+		SessionType:      sessionType,
+		ClientMsgID:      idutil.GetMsgIDByMD5(req.SendID),
+		SenderPlatformID: req.PlatformID,
+		MsgFrom:          constant.SysMsgType,
+		ContentType:      constant.ConversationInputStatus,
+		CreateTime:       timeutil.GetCurrentTimestampByMill(),
+		Content:          []byte(content),
+	}
+	options := make(map[string]bool, 5)
+	m.SetOptions(options, false)
+	datautil.SetSwitchFromOptions(options, constant.IsOfflinePush, false)
+	datautil.SetSwitchFromOptions(options, constant.IsUnreadCount, false)
+	msgData.Options = options
+	return &msg.SendMsgReq{MsgData: msgData}, nil
+}
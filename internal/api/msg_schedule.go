@@ -0,0 +1,469 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/openimsdk/open-im-server/v3/pkg/apistruct"
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/apiresp"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/log"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/datautil"
+	"github.com/openimsdk/tools/utils/idutil"
+	"github.com/openimsdk/tools/utils/timeutil"
+)
+
+// ScheduledMessageStatus is the lifecycle state of a ScheduledMessage.
+type ScheduledMessageStatus string
+
+const (
+	ScheduledMessagePending   ScheduledMessageStatus = "pending"
+	ScheduledMessageCanceled  ScheduledMessageStatus = "canceled"
+	ScheduledMessageCompleted ScheduledMessageStatus = "completed"
+	ScheduledMessageFailed    ScheduledMessageStatus = "failed"
+)
+
+// ScheduledMessage is one scheduled or recurring delivery. It carries bson
+// tags for a Mongo-backed ScheduledMessageStore, but none ships in this
+// package; the in-memory fallback keeps every entry, finished or not, until
+// it is deleted. Active recurring entries are re-armed by advancing
+// DeliverAt from Cron on every successful dispatch.
+type ScheduledMessage struct {
+	ID                   string                 `json:"id" bson:"id"`
+	OwnerUserID          string                 `json:"ownerUserID" bson:"ownerUserID"`
+	SendMsg              *apistruct.SendMsg     `json:"sendMsg,omitempty" bson:"sendMsg,omitempty"`
+	BatchSendMsg         *apistruct.BatchSendMsgReq `json:"batchSendMsg,omitempty" bson:"batchSendMsg,omitempty"`
+	DeliverAt            int64                  `json:"deliverAt" bson:"deliverAt"`
+	Cron                 string                 `json:"cron,omitempty" bson:"cron,omitempty"`
+	Timezone             string                 `json:"timezone,omitempty" bson:"timezone,omitempty"`
+	MaxOccurrences       int                     `json:"maxOccurrences,omitempty" bson:"maxOccurrences,omitempty"`
+	Occurrences          int                     `json:"occurrences" bson:"occurrences"`
+	SkipIfSenderDeleted  bool                   `json:"skipIfSenderDeleted" bson:"skipIfSenderDeleted"`
+	IdempotencyKey       string                 `json:"idempotencyKey,omitempty" bson:"idempotencyKey,omitempty"`
+	Status               ScheduledMessageStatus `json:"status" bson:"status"`
+	LastError            string                 `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreateTime           int64                  `json:"createTime" bson:"createTime"`
+}
+
+// ScheduledMessageStore persists scheduled sends. A production deployment
+// must pass NewMessageApi a Mongo-backed implementation — keeping a TTL
+// index on deliverAt for one-shot jobs that have already fired — so
+// scheduled jobs survive a restart; no such implementation ships in this
+// package. newInMemoryScheduledMessageStore is only the single-process
+// fallback NewMessageApi uses when none is supplied, consulted by
+// RunScheduledDispatcher via PopDue, and loses all job state on restart.
+type ScheduledMessageStore interface {
+	Create(ctx context.Context, job *ScheduledMessage) error
+	List(ctx context.Context, ownerUserID string) ([]*ScheduledMessage, error)
+	Get(ctx context.Context, id string) (*ScheduledMessage, error)
+	Delete(ctx context.Context, id string) error
+	Cancel(ctx context.Context, id string) error
+	// PopDue returns and claims up to limit pending jobs whose DeliverAt has
+	// elapsed, so a redelivered dispatcher tick cannot double-send them.
+	PopDue(ctx context.Context, now int64, limit int) ([]*ScheduledMessage, error)
+	// Reschedule advances a recurring job to its next occurrence, or marks it
+	// completed/failed when it has none left.
+	Reschedule(ctx context.Context, id string, nextDeliverAt int64, status ScheduledMessageStatus, lastError string) error
+}
+
+type inMemoryScheduledMessageStore struct {
+	mu   sync.Mutex
+	jobs map[string]*ScheduledMessage
+}
+
+func newInMemoryScheduledMessageStore() *inMemoryScheduledMessageStore {
+	return &inMemoryScheduledMessageStore{jobs: make(map[string]*ScheduledMessage)}
+}
+
+func (s *inMemoryScheduledMessageStore) Create(_ context.Context, job *ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.jobs {
+		if job.IdempotencyKey != "" && existing.IdempotencyKey == job.IdempotencyKey {
+			return errs.ErrArgs.WrapMsg("a scheduled message with this idempotencyKey already exists")
+		}
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *inMemoryScheduledMessageStore) List(_ context.Context, ownerUserID string) ([]*ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*ScheduledMessage, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if job.OwnerUserID == ownerUserID {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *inMemoryScheduledMessageStore) Get(_ context.Context, id string) (*ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, errs.ErrRecordNotFound.WrapMsg("scheduled message not found", "id", id)
+	}
+	return job, nil
+}
+
+func (s *inMemoryScheduledMessageStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *inMemoryScheduledMessageStore) Cancel(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return errs.ErrRecordNotFound.WrapMsg("scheduled message not found", "id", id)
+	}
+	job.Status = ScheduledMessageCanceled
+	return nil
+}
+
+func (s *inMemoryScheduledMessageStore) PopDue(_ context.Context, now int64, limit int) ([]*ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*ScheduledMessage
+	for _, job := range s.jobs {
+		if job.Status == ScheduledMessagePending && job.DeliverAt <= now {
+			due = append(due, job)
+			if len(due) >= limit {
+				break
+			}
+		}
+	}
+	return due, nil
+}
+
+func (s *inMemoryScheduledMessageStore) Reschedule(_ context.Context, id string, nextDeliverAt int64, status ScheduledMessageStatus, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return errs.ErrRecordNotFound.WrapMsg("scheduled message not found", "id", id)
+	}
+	job.Occurrences++
+	job.LastError = lastError
+	if status != "" {
+		job.Status = status
+		return nil
+	}
+	job.DeliverAt = nextDeliverAt
+	return nil
+}
+
+// ScheduleSendMsg registers a scheduled (or, with Cron set, recurring)
+// message for later delivery by the leader-elected dispatcher.
+func (m *MessageApi) ScheduleSendMsg(c *gin.Context) {
+	var req apistruct.ScheduleSendMsgReq
+	if err := c.BindJSON(&req); err != nil {
+		apiresp.GinError(c, errs.ErrArgs.WithDetail(err.Error()).Wrap())
+		return
+	}
+	if req.DeliverAt <= 0 {
+		apiresp.GinError(c, errs.ErrArgs.WrapMsg("deliverAt is required"))
+		return
+	}
+	if req.Cron != "" {
+		if _, err := parseEveryCron(req.Cron); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+	// Timezone-aware cron math needs a full parser (e.g. robfig/cron) that
+	// isn't a dependency of this package yet; reject it now instead of
+	// silently scheduling in UTC and surprising the caller later.
+	if req.Timezone != "" && req.Timezone != "UTC" {
+		apiresp.GinError(c, errs.ErrArgs.WrapMsg("only the UTC timezone is supported"))
+		return
+	}
+	ownerUserID := mcontext.GetOpUserID(c)
+
+	var templateSendMsg apistruct.SendMsg
+	switch {
+	case req.BatchSendMsg != nil:
+		templateSendMsg = req.BatchSendMsg.SendMsg
+	case req.SendMsg != nil:
+		templateSendMsg = *req.SendMsg
+	default:
+		apiresp.GinError(c, errs.ErrArgs.WrapMsg("sendMsg or batchSendMsg is required"))
+		return
+	}
+	caps, err := m.permissions.Resolve(c, templateSendMsg.SendID, templateSendMsg.GroupID)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	required := []Capability{CapabilityForContentType(templateSendMsg.ContentType)}
+	if req.BatchSendMsg != nil {
+		required = append(required, CapBatchSend)
+		if req.BatchSendMsg.IsSendAll {
+			required = append(required, CapSendToAll)
+		}
+	}
+	if err := Require(caps, required...); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if err := m.requireImpersonation(c, templateSendMsg.SendID, templateSendMsg.GroupID); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	// Decode the content now (same path SendMessage uses) purely to learn
+	// whether it targets "@all", which needs its own capability on top of
+	// the content-type check above; this also surfaces a malformed payload
+	// at schedule time instead of only at delivery time.
+	sendMsgReq, err := m.getSendMsgReq(c, templateSendMsg)
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	if datautil.Contain("all", sendMsgReq.MsgData.AtUserIDList...) {
+		if err := Require(caps, CapSendAtAll); err != nil {
+			apiresp.GinError(c, err)
+			return
+		}
+	}
+
+	// ID is always derived from the current time so two jobs from the same
+	// owner never collide, even when IdempotencyKey is left empty;
+	// inMemoryScheduledMessageStore.Create separately rejects a reused
+	// IdempotencyKey, which is a distinct duplicate-submission check.
+	job := &ScheduledMessage{
+		ID:                  idutil.GetMsgIDByMD5(ownerUserID + strconv.FormatInt(timeutil.GetCurrentTimestampByMill(), 10)),
+		OwnerUserID:         ownerUserID,
+		SendMsg:             req.SendMsg,
+		BatchSendMsg:        req.BatchSendMsg,
+		DeliverAt:           req.DeliverAt,
+		Cron:                req.Cron,
+		Timezone:            req.Timezone,
+		MaxOccurrences:      req.MaxOccurrences,
+		SkipIfSenderDeleted: req.SkipIfSenderDeleted,
+		IdempotencyKey:      req.IdempotencyKey,
+		Status:              ScheduledMessagePending,
+	}
+	if err := m.scheduledMsgs.Create(c, job); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, job)
+}
+
+// ListScheduledMsgs returns every scheduled/recurring job owned by the caller.
+func (m *MessageApi) ListScheduledMsgs(c *gin.Context) {
+	jobs, err := m.scheduledMsgs.List(c, mcontext.GetOpUserID(c))
+	if err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, jobs)
+}
+
+// DeleteScheduledMsg permanently removes a scheduled job.
+func (m *MessageApi) DeleteScheduledMsg(c *gin.Context) {
+	if err := m.scheduledMsgs.Delete(c, c.Param("id")); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, nil)
+}
+
+// CancelScheduledMsg stops a job from firing again without deleting its
+// history, so GET /msg/scheduled still reports it.
+func (m *MessageApi) CancelScheduledMsg(c *gin.Context) {
+	if err := m.scheduledMsgs.Cancel(c, c.Param("id")); err != nil {
+		apiresp.GinError(c, err)
+		return
+	}
+	apiresp.GinSuccess(c, nil)
+}
+
+// ScheduleLeaderElector reports whether this API replica currently holds the
+// scheduler leader lease, so only one replica dispatches due jobs. A
+// multi-replica deployment must supply an implementation backed by a
+// distributed lock (e.g. Redis); alwaysLeader is the single-replica default.
+type ScheduleLeaderElector interface {
+	IsLeader() bool
+}
+
+// alwaysLeader is the ScheduleLeaderElector used when the caller does not
+// have a distributed lock wired up, i.e. a single API replica is running.
+// Running it against more than one replica would double-dispatch every job.
+type alwaysLeader struct{}
+
+func (alwaysLeader) IsLeader() bool { return true }
+
+const defaultScheduledDispatchTick = time.Second
+
+// StartScheduledDispatcher is the entry point a server's main wiring calls
+// once at startup to run the scheduled/recurring message dispatcher for the
+// life of the process; it returns immediately, running RunScheduledDispatcher
+// in its own goroutine. Pass elector as nil to use the single-replica default.
+func (m *MessageApi) StartScheduledDispatcher(ctx context.Context, elector ScheduleLeaderElector) {
+	if elector == nil {
+		elector = alwaysLeader{}
+	}
+	go m.RunScheduledDispatcher(ctx, elector, defaultScheduledDispatchTick)
+}
+
+// RunScheduledDispatcher polls scheduledMsgs for due jobs on every tick and
+// delivers them through the same getSendMsgReq -> m.Client.SendMsg path used
+// by the synchronous send handlers. It blocks until ctx is canceled, so
+// callers run it in its own goroutine (StartScheduledDispatcher does this
+// for you).
+func (m *MessageApi) RunScheduledDispatcher(ctx context.Context, elector ScheduleLeaderElector, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if elector != nil && !elector.IsLeader() {
+				continue
+			}
+			m.dispatchDueScheduledMsgs(ctx)
+		}
+	}
+}
+
+const scheduledDispatchBatchSize = 100
+
+func (m *MessageApi) dispatchDueScheduledMsgs(ctx context.Context) {
+	due, err := m.scheduledMsgs.PopDue(ctx, time.Now().UnixMilli(), scheduledDispatchBatchSize)
+	if err != nil {
+		log.ZError(ctx, "failed to pop due scheduled messages", err)
+		return
+	}
+	for _, job := range due {
+		m.dispatchScheduledMsg(ctx, job)
+	}
+}
+
+func (m *MessageApi) dispatchScheduledMsg(ctx context.Context, job *ScheduledMessage) {
+	sendErr := m.deliverScheduledMsg(ctx, job)
+
+	nextDeliverAt, hasNext := nextCronOccurrence(job)
+	switch {
+	case sendErr == nil && hasNext:
+		_ = m.scheduledMsgs.Reschedule(ctx, job.ID, nextDeliverAt, "", "")
+	case sendErr == nil:
+		_ = m.scheduledMsgs.Reschedule(ctx, job.ID, 0, ScheduledMessageCompleted, "")
+	default:
+		_ = m.scheduledMsgs.Reschedule(ctx, job.ID, 0, ScheduledMessageFailed, sendErr.Error())
+		m.notifyScheduledMsgFailure(ctx, job, sendErr)
+	}
+}
+
+func (m *MessageApi) deliverScheduledMsg(ctx context.Context, job *ScheduledMessage) error {
+	if job.SkipIfSenderDeleted {
+		deleted, err := m.senderIsDeleted(ctx, job.OwnerUserID)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			return nil
+		}
+	}
+	switch {
+	case job.BatchSendMsg != nil:
+		recvIDs, err := m.resolveBatchRecipients(ctx, job.BatchSendMsg)
+		if err != nil {
+			return err
+		}
+		sendMsgReq, err := m.getSendMsgReq(ctx, job.BatchSendMsg.SendMsg)
+		if err != nil {
+			return err
+		}
+		runBatchSend(recvIDs, defaultBatchConcurrency, 0, func(recvID string) *BatchRecipientResult {
+			return m.batchSendOne(ctx, sendMsgReq, recvID)
+		}, func(*BatchRecipientResult) {})
+		return nil
+	case job.SendMsg != nil:
+		sendMsgReq, err := m.getSendMsgReq(ctx, *job.SendMsg)
+		if err != nil {
+			return err
+		}
+		_, err = m.Client.SendMsg(ctx, sendMsgReq)
+		return err
+	default:
+		return errs.ErrArgs.WrapMsg("scheduled message has neither sendMsg nor batchSendMsg")
+	}
+}
+
+// nextCronOccurrence computes the next delivery time for a recurring job, or
+// ok=false once it is exhausted (MaxOccurrences reached, or no recurrence
+// configured). Timezone-aware standard cron expressions need a full parser
+// (e.g. robfig/cron) that isn't a dependency of this package yet, so only
+// the common "@every <duration>" interval form is supported for now; an
+// unrecognized Cron expression is treated as already exhausted.
+func nextCronOccurrence(job *ScheduledMessage) (int64, bool) {
+	if job.Cron == "" {
+		return 0, false
+	}
+	if job.MaxOccurrences > 0 && job.Occurrences+1 >= job.MaxOccurrences {
+		return 0, false
+	}
+	interval, err := parseEveryCron(job.Cron)
+	if err != nil {
+		return 0, false
+	}
+	return time.Now().Add(interval).UnixMilli(), true
+}
+
+func parseEveryCron(expr string) (time.Duration, error) {
+	const prefix = "@every "
+	if !strings.HasPrefix(expr, prefix) {
+		return 0, errs.ErrArgs.WrapMsg("unsupported cron expression; only \"@every <duration>\" is supported")
+	}
+	return time.ParseDuration(strings.TrimPrefix(expr, prefix))
+}
+
+// senderIsDeleted reports whether the scheduling owner's account no longer
+// exists, so a job created with SkipIfSenderDeleted can drop this occurrence
+// instead of delivering (or failing) on behalf of a removed account.
+func (m *MessageApi) senderIsDeleted(ctx context.Context, ownerUserID string) (bool, error) {
+	users, err := m.userClient.GetUsersInfo(ctx, []string{ownerUserID})
+	if err != nil {
+		return false, err
+	}
+	return len(users) == 0, nil
+}
+
+func (m *MessageApi) notifyScheduledMsgFailure(ctx context.Context, job *ScheduledMessage, sendErr error) {
+	text := "Your scheduled message failed to send permanently: " + sendErr.Error()
+	m.sendSystemNotification(ctx, &sdkws.MsgData{
+		SendID:      job.OwnerUserID,
+		RecvID:      job.OwnerUserID,
+		SessionType: constant.SingleChatType,
+	}, text)
+}
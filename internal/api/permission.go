@@ -0,0 +1,182 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/openimsdk/protocol/constant"
+	"github.com/openimsdk/protocol/sdkws"
+	"github.com/openimsdk/tools/errs"
+	"github.com/openimsdk/tools/mcontext"
+	"github.com/openimsdk/tools/utils/datautil"
+)
+
+// Capability is a fine-grained permission a sender may or may not hold for a
+// given send. Unlike the old blanket authverify.IsAdmin(c) gate, capabilities
+// are re-evaluated on every request, so a revoked capability takes effect on
+// the very next send instead of requiring the user to be globally kicked.
+type Capability string
+
+const (
+	CapSendText           Capability = "canSendText"
+	CapSendPicture        Capability = "canSendPicture"
+	CapSendVoice          Capability = "canSendVoice"
+	CapSendVideo          Capability = "canSendVideo"
+	CapSendFile           Capability = "canSendFile"
+	CapSendCustom         Capability = "canSendCustom"
+	CapSendOANotification Capability = "canSendOANotification"
+	CapSendToAll          Capability = "canSendToAll"
+	CapSendAtAll          Capability = "canSendAtAll"
+	CapImpersonate        Capability = "canImpersonate"
+	CapBatchSend          Capability = "canBatchSend"
+)
+
+// CapabilityResolver resolves the non-admin capability sources: per-user role
+// (stored in the user RPC) and per-group role (stored in the group RPC),
+// layered on top of the admin list by MessagePermissionChecker.
+type CapabilityResolver interface {
+	ResolveUserCapabilities(ctx context.Context, userID string) (map[Capability]bool, error)
+	ResolveGroupCapabilities(ctx context.Context, groupID, userID string) (map[Capability]bool, error)
+}
+
+// allCapabilities is the capability set granted to admins.
+func allCapabilities() map[Capability]bool {
+	return map[Capability]bool{
+		CapSendText: true, CapSendPicture: true, CapSendVoice: true, CapSendVideo: true,
+		CapSendFile: true, CapSendCustom: true, CapSendOANotification: true,
+		CapSendToAll: true, CapSendAtAll: true, CapImpersonate: true, CapBatchSend: true,
+	}
+}
+
+// MessagePermissionChecker replaces the blanket authverify.IsAdmin(c) gate on
+// SendMessage, SendBusinessNotification and BatchSendMsg with fine-grained,
+// per-request capability checks.
+type MessagePermissionChecker struct {
+	imAdminUserID []string
+	resolver      CapabilityResolver
+}
+
+func NewMessagePermissionChecker(imAdminUserID []string, resolver CapabilityResolver) *MessagePermissionChecker {
+	return &MessagePermissionChecker{imAdminUserID: imAdminUserID, resolver: resolver}
+}
+
+// Resolve returns the full capability set for userID within groupID (groupID
+// may be empty for 1:1 sends), re-evaluated on every call so a permission
+// revoked mid-session is reflected on the very next send.
+func (p *MessagePermissionChecker) Resolve(ctx context.Context, userID, groupID string) (map[Capability]bool, error) {
+	if datautil.Contain(userID, p.imAdminUserID...) {
+		return allCapabilities(), nil
+	}
+	caps, err := p.resolver.ResolveUserCapabilities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if groupID == "" {
+		return caps, nil
+	}
+	groupCaps, err := p.resolver.ResolveGroupCapabilities(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[Capability]bool, len(caps)+len(groupCaps))
+	for cap, ok := range caps {
+		merged[cap] = ok
+	}
+	for cap, ok := range groupCaps {
+		if ok {
+			merged[cap] = true
+		}
+	}
+	return merged, nil
+}
+
+// errNoCapability names the missing capability so clients can surface a
+// precise, actionable error instead of a generic "no permission".
+func errNoCapability(cap Capability) error {
+	return errs.ErrNoPermission.WrapMsg("missing capability: " + string(cap))
+}
+
+// Require checks that caps grants every capability in required, returning an
+// ErrNoPermission naming the first missing one.
+func Require(caps map[Capability]bool, required ...Capability) error {
+	for _, cap := range required {
+		if !caps[cap] {
+			return errNoCapability(cap)
+		}
+	}
+	return nil
+}
+
+// CapabilityForContentType maps a message content type onto the capability
+// required to send it. Unknown content types fall back to CapSendCustom.
+func CapabilityForContentType(contentType int32) Capability {
+	switch contentType {
+	case constant.Text, constant.AtText, constant.MarkdownText:
+		return CapSendText
+	case constant.Picture:
+		return CapSendPicture
+	case constant.Voice:
+		return CapSendVoice
+	case constant.Video:
+		return CapSendVideo
+	case constant.File:
+		return CapSendFile
+	case constant.OANotification:
+		return CapSendOANotification
+	default:
+		return CapSendCustom
+	}
+}
+
+// checkSendMessagePermission evaluates every capability required to deliver
+// md as md.SendID currently holds them, including the "@all" AtElem case,
+// and checks impersonation (sending on behalf of a different sendID) against
+// the operator making the call.
+func (m *MessageApi) checkSendMessagePermission(c *gin.Context, md *sdkws.MsgData) error {
+	caps, err := m.permissions.Resolve(c, md.SendID, md.GroupID)
+	if err != nil {
+		return err
+	}
+	required := []Capability{CapabilityForContentType(md.ContentType)}
+	if datautil.Contain("all", md.AtUserIDList...) {
+		required = append(required, CapSendAtAll)
+	}
+	if err := Require(caps, required...); err != nil {
+		return err
+	}
+	return m.requireImpersonation(c, md.SendID, md.GroupID)
+}
+
+// requireImpersonation authorizes sending as sendID on behalf of someone
+// else. CapImpersonate must be held by the operator actually making the
+// call, not by the nominal sendID being impersonated — resolving it against
+// sendID would let a non-admin operator pass this check for free simply by
+// claiming to send as an admin, since Resolve(adminID) returns every
+// capability.
+func (m *MessageApi) requireImpersonation(ctx context.Context, sendID, groupID string) error {
+	opUserID := mcontext.GetOpUserID(ctx)
+	if sendID == opUserID {
+		return nil
+	}
+	opCaps, err := m.permissions.Resolve(ctx, opUserID, groupID)
+	if err != nil {
+		return err
+	}
+	return Require(opCaps, CapImpersonate)
+}
+
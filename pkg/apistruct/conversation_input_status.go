@@ -0,0 +1,40 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apistruct
+
+// ConversationInputStatusReq carries an ephemeral "typing" style indicator
+// that a client wants to broadcast into a 1:1 or group conversation.
+type ConversationInputStatusReq struct {
+	SendID              string `json:"sendID" binding:"required"`
+	FocusConversationID string `json:"focusConversationID" binding:"required"`
+	// GroupID, when set, marks this as a group conversation (FocusConversationID
+	// is then the group's conversation ID rather than a peer user ID).
+	GroupID    string `json:"groupID"`
+	PlatformID int32  `json:"platformID"`
+	// Status is a coarse indicator such as "typing", "recording_voice" or "picking_image".
+	Status string `json:"status" binding:"required"`
+	// CustomType lets clients define their own indicator when Status is "custom".
+	CustomType string `json:"customType"`
+	// ExpireMs tells receivers how long to keep showing the indicator before auto-clearing it.
+	ExpireMs int64 `json:"expireMs"`
+}
+
+// CancelConversationInputStatusReq explicitly clears an input status published earlier.
+type CancelConversationInputStatusReq struct {
+	SendID              string `json:"sendID" binding:"required"`
+	FocusConversationID string `json:"focusConversationID" binding:"required"`
+	GroupID             string `json:"groupID"`
+	PlatformID          int32  `json:"platformID"`
+}
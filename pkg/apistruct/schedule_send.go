@@ -0,0 +1,33 @@
+// Copyright © 2023 OpenIM. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apistruct
+
+// ScheduleSendMsgReq registers a single future send (SendMsg) or a future
+// broadcast (BatchSendMsg) for delivery at DeliverAt, optionally recurring
+// per Cron.
+type ScheduleSendMsgReq struct {
+	SendMsg      *SendMsg          `json:"sendMsg,omitempty"`
+	BatchSendMsg *BatchSendMsgReq  `json:"batchSendMsg,omitempty"`
+	DeliverAt    int64             `json:"deliverAt" binding:"required"`
+	// Cron, when set, makes this a recurring job. See nextCronOccurrence in
+	// the api package for the currently supported expression syntax.
+	Cron                string `json:"cron"`
+	Timezone            string `json:"timezone"`
+	MaxOccurrences      int    `json:"maxOccurrences"`
+	SkipIfSenderDeleted bool   `json:"skipIfSenderDeleted"`
+	// IdempotencyKey lets a redelivered scheduling request be rejected
+	// instead of creating a duplicate job.
+	IdempotencyKey string `json:"idempotencyKey"`
+}